@@ -8,6 +8,42 @@ genconstructor is constructor generator for Go.
     }
 ```
 
+`-o` generates a functional-options constructor instead of a fixed
+positional one. A field tagged `required:"[constValue]"` keeps the given
+value as a default applied before the options run; a bare `required:""`
+(no const value) instead becomes a positional parameter of `NewFoo` that
+must be supplied at call time; every other field gets a `WithField`
+option instead.
+
+```go
+    //genconstructor -o
+    type Foo struct {
+        key   string `required:"\"default\""`
+        id    string `required:""`
+        count int
+    }
+```
+
+A field tagged `required:""` (no const value) may also carry a
+`validate:"..."` tag (e.g. `validate:"nonzero,min=1,max=255,regexp=^[a-z]+$,oneof=a|b|c"`).
+When any field on a struct has one, the generated `NewFoo` returns
+`(*Foo, error)` and checks each rule, in tag order, before constructing
+the struct. `-o` and `-b` ignore `validate` tags.
+
+`-b` generates a builder instead: a `FooBuilder` struct, a
+`NewFooBuilder() *FooBuilder`, chainable `SetX(v T) *FooBuilder`
+setters for every non-const field, and a `Build() (*Foo, error)` that
+errors if a `required:""` field was never set.
+
+Generic structs are supported: the struct's type parameters are carried
+over to `New[T any](...)` and to the returned `Foo[T]`.
+
+Teams that would rather describe their structs in a declarative spec
+instead of hand-writing marker comments can call RunFromSchema with a
+YAML or JSON file laying out packages, structs, fields and the same
+required/const/super/pointer/extends options described above; it
+generates both the struct definitions and their constructors.
+
 with `go generate` command
 
 ```go
@@ -18,17 +54,25 @@ package genconstructor
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"go/ast"
 	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
-	"unicode"
 
-	"github.com/GuiltyMorishita/go-genutil/genutil"
 	"github.com/hori-ryota/go-strcase"
+	"golang.org/x/tools/go/packages"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -36,8 +80,60 @@ const (
 	pointerOpts   = "-p"
 	superOpts     = "-s"
 	extendsOpts   = "-e"
+	optionsOpts   = "-o"
+	builderOpts   = "-b"
+
+	validateTagKey = "validate"
 )
 
+// validationCheck is a single generated guard clause: when Cond evaluates
+// to true the constructor returns an error built from Msg.
+type validationCheck struct {
+	Cond string
+	Msg  string
+}
+
+// validatorBuilders is the registry of supported `validate:"..."` checks.
+// Each builder renders the Go condition/message for one rule and reports
+// any extra imports the condition needs.
+var validatorBuilders = map[string]func(varName, fieldName, param string) (validationCheck, map[string]string){
+	"nonzero": func(varName, fieldName, param string) (validationCheck, map[string]string) {
+		return validationCheck{
+			Cond: fmt.Sprintf("reflect.ValueOf(%s).IsZero()", varName),
+			Msg:  fmt.Sprintf("%s must not be zero", fieldName),
+		}, map[string]string{"reflect": "reflect"}
+	},
+	"min": func(varName, fieldName, param string) (validationCheck, map[string]string) {
+		return validationCheck{
+			Cond: fmt.Sprintf("%s < %s", varName, param),
+			Msg:  fmt.Sprintf("%s must be >= %s", fieldName, param),
+		}, nil
+	},
+	"max": func(varName, fieldName, param string) (validationCheck, map[string]string) {
+		return validationCheck{
+			Cond: fmt.Sprintf("%s > %s", varName, param),
+			Msg:  fmt.Sprintf("%s must be <= %s", fieldName, param),
+		}, nil
+	},
+	"regexp": func(varName, fieldName, param string) (validationCheck, map[string]string) {
+		return validationCheck{
+			Cond: fmt.Sprintf("!regexp.MustCompile(%q).MatchString(%s)", param, varName),
+			Msg:  fmt.Sprintf("%s must match %s", fieldName, param),
+		}, map[string]string{"regexp": "regexp"}
+	},
+	"oneof": func(varName, fieldName, param string) (validationCheck, map[string]string) {
+		opts := strings.Split(param, "|")
+		conds := make([]string, 0, len(opts))
+		for _, o := range opts {
+			conds = append(conds, fmt.Sprintf("%s != %q", varName, o))
+		}
+		return validationCheck{
+			Cond: strings.Join(conds, " && "),
+			Msg:  fmt.Sprintf("%s must be one of %s", fieldName, param),
+		}, nil
+	},
+}
+
 type Option func(o *option)
 
 type option struct {
@@ -57,7 +153,15 @@ func WithGeneratorName(generatorName string) Option {
 	}
 }
 
-func Run(targetDir string, newWriter func(pkg *ast.Package) io.Writer, opts ...Option) error {
+// rawField is a single struct field resolved through the type checker,
+// with promoted fields from embedded structs already flattened in.
+type rawField struct {
+	Name string
+	Tag  reflect.StructTag
+	Type types.Type
+}
+
+func Run(targetDir string, newWriter func(pkg *packages.Package) io.Writer, opts ...Option) error {
 	option := option{
 		generatorName: "go-genconstructor",
 	}
@@ -65,160 +169,562 @@ func Run(targetDir string, newWriter func(pkg *ast.Package) io.Writer, opts ...O
 		opt(&option)
 	}
 
-	walkers, err := genutil.DirToAstWalker(targetDir, option.fileFilter)
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+			packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps,
+		Dir: targetDir,
+	}, ".")
 	if err != nil {
 		return err
 	}
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return pkg.Errors[0]
+		}
+	}
 
-	for _, walker := range walkers {
+	for _, pkg := range pkgs {
 		body := new(bytes.Buffer)
 		importPackages := make(map[string]string, 10)
-		for _, spec := range walker.AllStructSpecs() {
-			docs := make([]*ast.Comment, 0, 10)
-			if spec.Doc != nil {
-				docs = append(docs, spec.Doc.List...)
-			}
-			if decl := walker.TypeSpecToGenDecl(spec); decl.Doc != nil {
-				docs = append(docs, decl.Doc.List...)
-			}
-			if len(docs) == 0 {
-				continue
+
+		for i, file := range pkg.Syntax {
+			if option.fileFilter != nil {
+				finfo, err := os.Stat(pkg.CompiledGoFiles[i])
+				if err != nil {
+					return err
+				}
+				if !option.fileFilter(finfo) {
+					continue
+				}
 			}
-			hasMarker := false
-			hasPointerOpts := false
-			hasSuperOpts := false
-			hasExtendsOpts := false
-			for _, comment := range docs {
-				if strings.HasPrefix(strings.TrimSpace(comment.Text), commentMarker) {
-					hasMarker = true
-					for _, s := range strings.Fields(comment.Text) {
-						if s == pointerOpts {
-							hasPointerOpts = true
+
+			for _, decl := range file.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || genDecl.Tok != token.TYPE {
+					continue
+				}
+				for _, s := range genDecl.Specs {
+					spec, ok := s.(*ast.TypeSpec)
+					if !ok {
+						continue
+					}
+					if _, ok := spec.Type.(*ast.StructType); !ok {
+						continue
+					}
+
+					docs := make([]*ast.Comment, 0, 10)
+					if spec.Doc != nil {
+						docs = append(docs, spec.Doc.List...)
+					}
+					if genDecl.Doc != nil {
+						docs = append(docs, genDecl.Doc.List...)
+					}
+					if len(docs) == 0 {
+						continue
+					}
+
+					hasMarker := false
+					hasPointerOpts := false
+					hasSuperOpts := false
+					hasExtendsOpts := false
+					hasOptionsOpts := false
+					hasBuilderOpts := false
+					for _, comment := range docs {
+						if strings.HasPrefix(strings.TrimSpace(comment.Text), commentMarker) {
+							hasMarker = true
+							for _, word := range strings.Fields(comment.Text) {
+								if word == pointerOpts {
+									hasPointerOpts = true
+									break
+								}
+								if word == superOpts {
+									hasSuperOpts = true
+									break
+								}
+								if word == extendsOpts {
+									hasExtendsOpts = true
+									break
+								}
+								if word == optionsOpts {
+									hasOptionsOpts = true
+									break
+								}
+								if word == builderOpts {
+									hasBuilderOpts = true
+									break
+								}
+							}
 							break
 						}
-						if s == superOpts {
-							hasSuperOpts = true
-							break
+					}
+					if !hasMarker {
+						continue
+					}
+
+					var fields []rawField
+					if hasOptionsOpts || hasBuilderOpts {
+						// Options/builder assign through a field selector
+						// (o.X = v), which Go allows for a promoted field,
+						// so these two modes flatten embedded structs in.
+						fields, err = flattenedStructFields(pkg, spec)
+					} else {
+						// Every other mode emits a composite literal
+						// (Foo{X: v}), where a promoted field's name isn't
+						// a legal key -- keep embedded structs as a single
+						// field so a required/super tag on the embedded
+						// field itself is still seen.
+						fields, err = structFields(pkg, spec)
+					}
+					if err != nil {
+						return err
+					}
+
+					imp := newImportQualifier(pkg.Types, importPackages)
+					typeParamDecl, typeArgs := typeParamsOf(pkg, spec, imp.qualify)
+
+					if hasOptionsOpts {
+						fieldInfos := make([]FieldInfo, 0, len(fields))
+						for _, f := range fields {
+							info, err := buildFieldInfo(pkg, spec.Pos(), f, imp, importPackages, false)
+							if err != nil {
+								return err
+							}
+							fieldInfos = append(fieldInfos, info)
 						}
-						if s == extendsOpts {
-							hasExtendsOpts = true
-							break
+
+						b, err := renderOptions(tmplParam{
+							StructName: spec.Name.Name,
+							Fields:     fieldInfos,
+							Pointer:    hasPointerOpts,
+							TypeParams: typeParamDecl,
+							TypeArgs:   typeArgs,
+						})
+						if err != nil {
+							return err
 						}
+						body.Write(b)
+						continue
+					}
+
+					if hasBuilderOpts {
+						fieldInfos := make([]FieldInfo, 0, len(fields))
+						hasRequiredField := false
+						for _, f := range fields {
+							info, err := buildFieldInfo(pkg, spec.Pos(), f, imp, importPackages, false)
+							if err != nil {
+								return err
+							}
+							if info.Required {
+								hasRequiredField = true
+							}
+							fieldInfos = append(fieldInfos, info)
+						}
+
+						if hasRequiredField {
+							importPackages["fmt"] = "fmt"
+						}
+
+						b, err := renderBuilder(tmplParam{
+							StructName: spec.Name.Name,
+							Fields:     fieldInfos,
+							TypeParams: typeParamDecl,
+							TypeArgs:   typeArgs,
+						})
+						if err != nil {
+							return err
+						}
+						body.Write(b)
+						continue
+					}
+
+					var superName string
+					hasValidation := false
+					fieldInfos := make([]FieldInfo, 0, len(fields))
+					for _, f := range fields {
+						_, hasRequiredTag := f.Tag.Lookup("required")
+						_, hasSuperTag := f.Tag.Lookup("super")
+						if !hasRequiredTag && !hasSuperTag {
+							continue
+						}
+
+						info, err := buildFieldInfo(pkg, spec.Pos(), f, imp, importPackages, true)
+						if err != nil {
+							return err
+						}
+						if len(info.Validations) > 0 {
+							hasValidation = true
+							importPackages["fmt"] = "fmt"
+						}
+						if hasSuperTag {
+							superName = f.Name
+						}
+						fieldInfos = append(fieldInfos, info)
+					}
+
+					var interfaceName string
+					if hasSuperOpts {
+						interfaceName = strcase.ToUpperCamel(spec.Name.Name)
 					}
-					break
+					if hasExtendsOpts {
+						matched := match(strcase.SplitIntoWords(strcase.ToUpperCamel(superName)), strcase.SplitIntoWords(strcase.ToUpperCamel(spec.Name.Name)))
+						interfaceName = strings.Join(matched, "")
+					}
+
+					b, err := renderConstructor(tmplParam{
+						StructName:    spec.Name.Name,
+						InterfaceName: interfaceName,
+						Fields:        fieldInfos,
+						Pointer:       hasPointerOpts,
+						Super:         hasSuperOpts,
+						Extends:       hasExtendsOpts,
+						HasValidation: hasValidation,
+						TypeParams:    typeParamDecl,
+						TypeArgs:      typeArgs,
+					})
+					if err != nil {
+						return err
+					}
+					body.Write(b)
 				}
 			}
-			if !hasMarker {
-				continue
-			}
+		}
 
-			structType := spec.Type.(*ast.StructType)
+		if body.Len() == 0 {
+			continue
+		}
 
-			var superName string
-			fieldInfos := make([]FieldInfo, 0, len(structType.Fields.List))
-			for _, field := range structType.Fields.List {
-				if field.Tag == nil {
-					continue
-				}
-				tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+		out := new(bytes.Buffer)
 
-				constValue, hasRequiredTag := tag.Lookup("required")
+		err = template.Must(template.New("out").Parse(`
+			// Code generated by {{ .GeneratorName }}; DO NOT EDIT.
 
-				_, hasSuperTag := tag.Lookup("super")
-				if !hasRequiredTag && !hasSuperTag {
-					continue
-				}
+			package {{ .PackageName }}
 
-				fieldName := genutil.ParseFieldName(field)
-				typePrinter, err := walker.ToTypePrinter(field.Type)
-				if err != nil {
-					return err
+			{{ .ImportPackages }}
+
+			{{ .Body }}
+		`)).Execute(out, map[string]string{
+			"GeneratorName":  option.generatorName,
+			"PackageName":    pkg.Name,
+			"ImportPackages": formatImports(importPackages),
+			"Body":           body.String(),
+		})
+		if err != nil {
+			return err
+		}
+
+		str, err := format.Source(out.Bytes())
+		if err != nil {
+			return err
+		}
+		writer := newWriter(pkg)
+		if closer, ok := writer.(io.Closer); ok {
+			defer closer.Close()
+		}
+		if _, err := writer.Write(str); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderOptions renders the `-o` functional-options constructor for p. A
+// field tagged with a const default is applied before the options run; a
+// field tagged `required:""` (no const value) instead becomes a
+// positional parameter of NewFoo, since it must be supplied and has no
+// sensible option-only default; every other field gets a WithField
+// option.
+func renderOptions(p tmplParam) ([]byte, error) {
+	tmpl, err := template.New("optionsConstructor").Funcs(map[string]interface{}{
+		"ToUpperCamel": strcase.ToUpperCamel,
+		"ToLowerCamel": strcase.ToLowerCamel,
+	}).Parse(`
+type {{ ToUpperCamel .StructName }}Option{{ .TypeParams }} func(*{{ .StructName }}{{ .TypeArgs }})
+
+{{ range .Fields }}
+{{- if and (not .ConstValue) (not .Required) }}
+func With{{ ToUpperCamel .Name }}{{ $.TypeParams }}(v {{ .Type }}) {{ ToUpperCamel $.StructName }}Option{{ $.TypeArgs }} {
+	return func(o *{{ $.StructName }}{{ $.TypeArgs }}) {
+		o.{{ .Name }} = v
+	}
+}
+{{ end }}
+{{- end }}
+func New{{ ToUpperCamel .StructName }}{{ .TypeParams }}(
+	{{- range .Fields }}
+		{{- if and (not .ConstValue) (.Required) }}
+	{{ ToLowerCamel .Name }} {{ .Type }},
+		{{- end }}
+	{{- end }}
+	opts ...{{ ToUpperCamel .StructName }}Option{{ .TypeArgs }},
+) {{ if .Pointer }}*{{ end }}{{ .StructName }}{{ .TypeArgs }} {
+	o := &{{ .StructName }}{{ .TypeArgs }}{
+		{{- range .Fields }}
+			{{- if .ConstValue }}
+				{{ .Name }}: {{ .ConstValue }},
+			{{- else if .Required }}
+				{{ .Name }}: {{ ToLowerCamel .Name }},
+			{{- end }}
+		{{- end }}
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return {{ if not .Pointer }}*{{ end }}o
+}
+`)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderConstructor renders the classic positional (or validating)
+// constructor for p. It returns unformatted source; the caller is
+// expected to run the assembled file through format.Source afterward.
+func renderConstructor(p tmplParam) ([]byte, error) {
+	tmpl, err := template.New("constructor").Funcs(map[string]interface{}{
+		"ToUpperCamel": strcase.ToUpperCamel,
+		"ToLowerCamel": strcase.ToLowerCamel,
+		"Quote":        strconv.Quote,
+	}).Parse(`
+func New{{ ToUpperCamel .StructName }}{{ .TypeParams }}(
+				{{- range .Fields }}
+					{{- if not .ConstValue }}
+						{{ if and ($.Extends) (eq (ToUpperCamel .Name) $.InterfaceName) }}x {{ $.InterfaceName }}{{ else }}{{ ToLowerCamel .Name }} {{ .Type }}{{ end }},
+					{{- end }}
+				{{- end }}
+			) ({{ if or (.Pointer) (.HasValidation) }}*{{ end }}{{ if or (.Super) (.Extends) }}{{ .InterfaceName }}{{ else }}{{ .StructName }}{{ .TypeArgs }}{{ end }}{{ if .HasValidation }}, error{{ end }}) {
+				{{- if .HasValidation }}
+					{{- range .Fields }}
+						{{- range .Validations }}
+				if {{ .Cond }} {
+					return nil, fmt.Errorf("%s", {{ .Msg | Quote }})
 				}
+						{{- end }}
+					{{- end }}
+				{{- end }}
+				return {{ if or (.Pointer) (.Super) (.Extends) (.HasValidation) }}&{{ end }}{{ .StructName }}{{ .TypeArgs }}{
+					{{- range .Fields }}
+						{{- if .ConstValue }}
+							{{ .Name }}: {{ .ConstValue }},
+						{{- else }}
+							{{ .Name }}: {{ if and ($.Extends) (eq (ToUpperCamel .Name) $.InterfaceName) }}x.(*{{ .Name }}){{ else }}{{ ToLowerCamel .Name }}{{ end }},
+						{{- end }}
+					{{- end }}
+				}{{ if .HasValidation }}, nil{{ end }}
+			}
+		`)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
-				fieldInfos = append(fieldInfos, FieldInfo{
-					Type:       typePrinter.Print(walker.PkgPath),
-					Name:       fieldName,
-					ConstValue: constValue,
-				})
+// renderBuilder renders the `-b` builder for a struct: a `FooBuilder`
+// wrapping a *Foo, chainable SetX methods, and a Build() that rejects
+// a missing required field. Each required field gets its own "set" flag
+// on the builder so Build() can tell "never called" apart from "called
+// with the zero value".
+func renderBuilder(p tmplParam) ([]byte, error) {
+	tmpl, err := template.New("builder").Funcs(map[string]interface{}{
+		"ToUpperCamel": strcase.ToUpperCamel,
+		"ToLowerCamel": strcase.ToLowerCamel,
+	}).Parse(`
+type {{ ToUpperCamel .StructName }}Builder{{ .TypeParams }} struct {
+	target *{{ .StructName }}{{ .TypeArgs }}
+	{{- range .Fields }}
+		{{- if and (not .ConstValue) (.Required) }}
+	{{ ToLowerCamel .Name }}Set bool
+		{{- end }}
+	{{- end }}
+}
+
+func New{{ ToUpperCamel .StructName }}Builder{{ .TypeParams }}() *{{ ToUpperCamel .StructName }}Builder{{ .TypeArgs }} {
+	return &{{ ToUpperCamel .StructName }}Builder{{ .TypeArgs }}{
+		target: &{{ .StructName }}{{ .TypeArgs }}{
+			{{- range .Fields }}
+				{{- if .ConstValue }}
+					{{ .Name }}: {{ .ConstValue }},
+				{{- end }}
+			{{- end }}
+		},
+	}
+}
+
+{{ range .Fields }}
+{{- if not .ConstValue }}
+func (b *{{ ToUpperCamel $.StructName }}Builder{{ $.TypeArgs }}) Set{{ ToUpperCamel .Name }}(v {{ .Type }}) *{{ ToUpperCamel $.StructName }}Builder{{ $.TypeArgs }} {
+	b.target.{{ .Name }} = v
+	{{- if .Required }}
+	b.{{ ToLowerCamel .Name }}Set = true
+	{{- end }}
+	return b
+}
+{{ end }}
+{{- end }}
+func (b *{{ ToUpperCamel .StructName }}Builder{{ .TypeArgs }}) Build() (*{{ .StructName }}{{ .TypeArgs }}, error) {
+	{{- range .Fields }}
+		{{- if .Required }}
+	if !b.{{ ToLowerCamel .Name }}Set {
+		return nil, fmt.Errorf("{{ .Name }} must be set")
+	}
+		{{- end }}
+	{{- end }}
+	return b.target, nil
+}
+`)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// schemaSpec is the declarative shape read by RunFromSchema. It mirrors
+// the tag vocabulary understood by Run: a field's `required`/`const` map
+// onto FieldInfo.Required and FieldInfo.ConstValue, and a field's `super`
+// names the field the struct-level Extends option matches against.
+type schemaSpec struct {
+	Structs []schemaStruct `yaml:"structs" json:"structs"`
+}
+
+type schemaStruct struct {
+	Name    string        `yaml:"name" json:"name"`
+	Package string        `yaml:"package" json:"package"`
+	Pointer bool          `yaml:"pointer" json:"pointer"`
+	Super   bool          `yaml:"super" json:"super"`
+	Extends bool          `yaml:"extends" json:"extends"`
+	Imports []string      `yaml:"imports" json:"imports"`
+	Fields  []schemaField `yaml:"fields" json:"fields"`
+}
+
+type schemaField struct {
+	Name     string `yaml:"name" json:"name"`
+	Type     string `yaml:"type" json:"type"`
+	Required bool   `yaml:"required" json:"required"`
+	Const    string `yaml:"const" json:"const"`
+	Super    bool   `yaml:"super" json:"super"`
+}
+
+// RunFromSchema reads a declarative YAML or JSON spec (selected by the
+// schemaPath extension) describing packages, structs, fields and
+// constructor options, and emits both the struct type definitions and
+// their constructors under outDir, one generated file per package.
+//
+// It drives the same rendering used by Run, so the generated
+// constructors follow the same rules as the marker-comment mode: a
+// field with a `const` becomes a fixed default, a field with `required`
+// (and no const) becomes a constructor parameter, and `super`/`extends`
+// on a struct behave as the `-s`/`-e` marker options do.
+func RunFromSchema(schemaPath string, outDir string, opts ...Option) error {
+	option := option{
+		generatorName: "go-genconstructor",
+	}
+	for _, opt := range opts {
+		opt(&option)
+	}
+
+	raw, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return err
+	}
+
+	var schema schemaSpec
+	switch ext := strings.ToLower(filepath.Ext(schemaPath)); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(raw, &schema); err != nil {
+			return err
+		}
+	case ".json":
+		if err := json.Unmarshal(raw, &schema); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("genconstructor: unsupported schema file extension %q", ext)
+	}
 
-				if hasSuperTag {
-					superName = fieldName
+	structsByPackage := make(map[string][]schemaStruct)
+	packageOrder := make([]string, 0, len(schema.Structs))
+	for _, st := range schema.Structs {
+		if _, ok := structsByPackage[st.Package]; !ok {
+			packageOrder = append(packageOrder, st.Package)
+		}
+		structsByPackage[st.Package] = append(structsByPackage[st.Package], st)
+	}
+
+	for _, pkgName := range packageOrder {
+		body := new(bytes.Buffer)
+		importPackages := make(map[string]string, 10)
+
+		for _, st := range structsByPackage[pkgName] {
+			for _, imp := range st.Imports {
+				importPackages[pathBase(imp)] = imp
+			}
+
+			fmt.Fprintf(body, "type %s struct {\n", st.Name)
+			var superName string
+			fieldInfos := make([]FieldInfo, 0, len(st.Fields))
+			for _, f := range st.Fields {
+				if tag := schemaFieldTag(f); tag != "" {
+					fmt.Fprintf(body, "%s %s `%s`\n", f.Name, f.Type, tag)
+				} else {
+					fmt.Fprintf(body, "%s %s\n", f.Name, f.Type)
 				}
 
-				// resolve imports
-				if constValue != "" {
-					ss := strings.FieldsFunc(constValue, func(c rune) bool {
-						return !unicode.IsLetter(c) && c != '.' && c != '_' && c != '-'
-					})
-					for _, s := range ss {
-						p, err := genutil.ToTypePrinter(
-							genutil.AstFileToImportMap(walker.ToFile(field)),
-							walker.PkgPath,
-							s,
-						)
-						if err != nil {
-							return err
-						}
-						for n, pkg := range p.ImportPkgMap(walker.PkgPath) {
-							importPackages[n] = pkg
-						}
-					}
+				if !f.Required && f.Const == "" && !f.Super {
 					continue
 				}
 
-				for n, pkg := range typePrinter.ImportPkgMap(walker.PkgPath) {
-					importPackages[n] = pkg
+				fieldInfos = append(fieldInfos, FieldInfo{
+					Type:       f.Type,
+					Name:       f.Name,
+					ConstValue: f.Const,
+					Required:   f.Required && f.Const == "",
+				})
+				if f.Super {
+					superName = f.Name
 				}
 			}
+			body.WriteString("}\n\n")
 
 			var interfaceName string
-			if hasSuperOpts {
-				interfaceName = strcase.ToUpperCamel(spec.Name.Name)
+			if st.Super {
+				interfaceName = strcase.ToUpperCamel(st.Name)
 			}
-			if hasExtendsOpts {
-				matched := match(strcase.SplitIntoWords(strcase.ToUpperCamel(superName)), strcase.SplitIntoWords(strcase.ToUpperCamel(spec.Name.Name)))
+			if st.Extends {
+				matched := match(strcase.SplitIntoWords(strcase.ToUpperCamel(superName)), strcase.SplitIntoWords(strcase.ToUpperCamel(st.Name)))
 				interfaceName = strings.Join(matched, "")
 			}
 
-			if err := template.Must(template.New("constructor").Funcs(map[string]interface{}{
-				"ToUpperCamel": strcase.ToUpperCamel,
-				"ToLowerCamel": strcase.ToLowerCamel,
-			}).Parse(`
-func New{{ ToUpperCamel .StructName }}(
-							{{- range .Fields }}
-								{{- if not .ConstValue }}
-									{{ if and ($.Extends) (eq (ToUpperCamel .Name) $.InterfaceName) }}x {{ $.InterfaceName }}{{ else }}{{ ToLowerCamel .Name }} {{ .Type }}{{ end }},
-								{{- end }}
-							{{- end }}
-						) {{ if .Pointer }}*{{ end }}{{ if or (.Super) (.Extends) }}{{ .InterfaceName }}{{ else }}{{ .StructName }}{{ end }} {
-							return {{ if or (.Pointer) (.Super) (.Extends) }}&{{ end }}{{ .StructName }}{
-								{{- range .Fields }}
-									{{- if .ConstValue }}
-										{{ .Name }}: {{ .ConstValue }},
-									{{- else }}
-										{{ .Name }}: {{ if and ($.Extends) (eq (ToUpperCamel .Name) $.InterfaceName) }}x.(*{{ .Name }}){{ else }}{{ ToLowerCamel .Name }}{{ end }},
-									{{- end }}
-								{{- end }}
-							}
-						}
-					`)).Execute(body, tmplParam{
-				StructName:    spec.Name.Name,
+			b, err := renderConstructor(tmplParam{
+				StructName:    st.Name,
 				InterfaceName: interfaceName,
 				Fields:        fieldInfos,
-				Pointer:       hasPointerOpts,
-				Super:         hasSuperOpts,
-				Extends:       hasExtendsOpts,
-			}); err != nil {
+				Pointer:       st.Pointer,
+				Super:         st.Super,
+				Extends:       st.Extends,
+			})
+			if err != nil {
 				return err
 			}
-		}
-		if body.Len() == 0 {
-			continue
+			body.Write(b)
 		}
 
 		out := new(bytes.Buffer)
-
-		err = template.Must(template.New("out").Parse(`
+		if err := template.Must(template.New("schemaOut").Parse(`
 			// Code generated by {{ .GeneratorName }}; DO NOT EDIT.
 
 			package {{ .PackageName }}
@@ -228,11 +734,10 @@ func New{{ ToUpperCamel .StructName }}(
 			{{ .Body }}
 		`)).Execute(out, map[string]string{
 			"GeneratorName":  option.generatorName,
-			"PackageName":    walker.Pkg.Name,
-			"ImportPackages": genutil.GoFmtImports(importPackages),
+			"PackageName":    pkgName,
+			"ImportPackages": formatImports(importPackages),
 			"Body":           body.String(),
-		})
-		if err != nil {
+		}); err != nil {
 			return err
 		}
 
@@ -240,11 +745,12 @@ func New{{ ToUpperCamel .StructName }}(
 		if err != nil {
 			return err
 		}
-		writer := newWriter(walker.Pkg)
-		if closer, ok := writer.(io.Closer); ok {
-			defer closer.Close()
+
+		pkgDir := filepath.Join(outDir, pkgName)
+		if err := os.MkdirAll(pkgDir, 0o755); err != nil {
+			return err
 		}
-		if _, err := writer.Write(str); err != nil {
+		if err := os.WriteFile(filepath.Join(pkgDir, pkgName+"_gen.go"), str, 0o644); err != nil {
 			return err
 		}
 	}
@@ -252,6 +758,267 @@ func New{{ ToUpperCamel .StructName }}(
 	return nil
 }
 
+func schemaFieldTag(f schemaField) string {
+	var parts []string
+	if f.Const != "" {
+		parts = append(parts, fmt.Sprintf("required:%q", f.Const))
+	} else if f.Required {
+		parts = append(parts, `required:""`)
+	}
+	if f.Super {
+		parts = append(parts, `super:"true"`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// structFields resolves spec's fields through the type checker. An
+// embedded struct is kept as a single field -- named after its type, as
+// Go's composite-literal syntax requires -- so a required/super tag on
+// the embedded field itself is still seen by the positional/-s/-e modes
+// that build Foo{...} literals. See flattenedStructFields for the
+// options/builder modes, which assign through a field selector instead
+// and so can see promoted fields too.
+func structFields(pkg *packages.Package, spec *ast.TypeSpec) ([]rawField, error) {
+	st, err := structTypeOf(pkg, spec)
+	if err != nil {
+		return nil, err
+	}
+	return directFields(st), nil
+}
+
+func directFields(st *types.Struct) []rawField {
+	fields := make([]rawField, 0, st.NumFields())
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		fields = append(fields, rawField{
+			Name: f.Name(),
+			Tag:  reflect.StructTag(st.Tag(i)),
+			Type: f.Type(),
+		})
+	}
+	return fields
+}
+
+// flattenedStructFields resolves spec's fields through the type checker,
+// flattening promoted fields from embedded structs in so -o/-b can
+// generate a WithX/SetX for them too. This only works because those two
+// modes assign through o.X = v, which Go allows for a promoted field --
+// unlike structFields, it must not be used by a mode that builds a
+// composite literal.
+func flattenedStructFields(pkg *packages.Package, spec *ast.TypeSpec) ([]rawField, error) {
+	st, err := structTypeOf(pkg, spec)
+	if err != nil {
+		return nil, err
+	}
+	return flattenFields(st), nil
+}
+
+func structTypeOf(pkg *packages.Package, spec *ast.TypeSpec) (*types.Struct, error) {
+	obj, ok := pkg.TypesInfo.Defs[spec.Name]
+	if !ok || obj == nil {
+		return nil, fmt.Errorf("%s: type information not found", spec.Name.Name)
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a named type", spec.Name.Name)
+	}
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a struct type", spec.Name.Name)
+	}
+	return st, nil
+}
+
+func flattenFields(st *types.Struct) []rawField {
+	fields := make([]rawField, 0, st.NumFields())
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if f.Embedded() {
+			if sub := embeddedStructType(f.Type()); sub != nil {
+				fields = append(fields, flattenFields(sub)...)
+				continue
+			}
+		}
+		fields = append(fields, rawField{
+			Name: f.Name(),
+			Tag:  reflect.StructTag(st.Tag(i)),
+			Type: f.Type(),
+		})
+	}
+	return fields
+}
+
+func embeddedStructType(t types.Type) *types.Struct {
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		return u
+	case *types.Pointer:
+		if s, ok := u.Elem().Underlying().(*types.Struct); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// importQualifier formats types.Type values relative to the package being
+// generated into, registering an entry in imports for every other package
+// it encounters along the way.
+type importQualifier struct {
+	self    *types.Package
+	imports map[string]string
+}
+
+func newImportQualifier(self *types.Package, imports map[string]string) *importQualifier {
+	return &importQualifier{self: self, imports: imports}
+}
+
+func (q *importQualifier) qualify(pkg *types.Package) string {
+	if pkg == nil || pkg == q.self {
+		return ""
+	}
+	q.imports[pkg.Name()] = pkg.Path()
+	return pkg.Name()
+}
+
+func (q *importQualifier) TypeString(t types.Type) string {
+	return types.TypeString(t, q.qualify)
+}
+
+// resolveConstValueImports type-checks a required:"..." const expression
+// as it would resolve at pos, recording the packages any qualified
+// identifiers in it belong to.
+func resolveConstValueImports(pkg *packages.Package, pos token.Pos, constValue string, importPackages map[string]string) error {
+	expr, err := parser.ParseExprFrom(pkg.Fset, "", constValue, 0)
+	if err != nil {
+		return err
+	}
+	info := &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+	if err := types.CheckExpr(pkg.Fset, pkg.Types, pos, expr, info); err != nil {
+		return err
+	}
+	for _, obj := range info.Uses {
+		if obj == nil {
+			continue
+		}
+		if pkgName, ok := obj.(*types.PkgName); ok {
+			importPackages[pkgName.Imported().Name()] = pkgName.Imported().Path()
+			continue
+		}
+		if objPkg := obj.Pkg(); objPkg != nil && objPkg != pkg.Types {
+			importPackages[objPkg.Name()] = objPkg.Path()
+		}
+	}
+	return nil
+}
+
+// buildFieldInfo turns a resolved field into the data the templates render
+// from: its printed type, its required const default (if any), and,
+// when withValidation is set, its validate:"..." guard clauses.
+func buildFieldInfo(pkg *packages.Package, specPos token.Pos, f rawField, imp *importQualifier, importPackages map[string]string, withValidation bool) (FieldInfo, error) {
+	constValue, hasRequiredTag := f.Tag.Lookup("required")
+	info := FieldInfo{
+		Name:       f.Name,
+		ConstValue: constValue,
+		Required:   hasRequiredTag && constValue == "",
+	}
+
+	if constValue != "" {
+		if err := resolveConstValueImports(pkg, specPos, constValue, importPackages); err != nil {
+			return FieldInfo{}, err
+		}
+		return info, nil
+	}
+
+	info.Type = imp.TypeString(f.Type)
+
+	if withValidation {
+		if validateValue, hasValidateTag := f.Tag.Lookup(validateTagKey); hasValidateTag {
+			varName := strcase.ToLowerCamel(f.Name)
+			for _, rule := range strings.Split(validateValue, ",") {
+				name, param := rule, ""
+				if idx := strings.Index(rule, "="); idx >= 0 {
+					name, param = rule[:idx], rule[idx+1:]
+				}
+				builder, ok := validatorBuilders[name]
+				if !ok {
+					continue
+				}
+				check, imports := builder(varName, f.Name, param)
+				info.Validations = append(info.Validations, check)
+				for n, pkgPath := range imports {
+					importPackages[n] = pkgPath
+				}
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// typeParamsOf renders spec's type parameters (if any) as a declaration
+// usable after a func/type name ("[T any]") and as a bare argument list
+// usable after a type name ("[T]").
+func typeParamsOf(pkg *packages.Package, spec *ast.TypeSpec, q types.Qualifier) (decl string, args string) {
+	if spec.TypeParams == nil || len(spec.TypeParams.List) == 0 {
+		return "", ""
+	}
+	obj, ok := pkg.TypesInfo.Defs[spec.Name]
+	if !ok || obj == nil {
+		return "", ""
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return "", ""
+	}
+	tp := named.TypeParams()
+	if tp == nil || tp.Len() == 0 {
+		return "", ""
+	}
+
+	params := make([]string, 0, tp.Len())
+	names := make([]string, 0, tp.Len())
+	for i := 0; i < tp.Len(); i++ {
+		p := tp.At(i)
+		params = append(params, fmt.Sprintf("%s %s", p.Obj().Name(), types.TypeString(p.Constraint(), q)))
+		names = append(names, p.Obj().Name())
+	}
+	return "[" + strings.Join(params, ", ") + "]", "[" + strings.Join(names, ", ") + "]"
+}
+
+// formatImports renders an import block from a map of local alias to
+// import path, omitting the alias whenever it matches the path's last
+// element.
+func formatImports(imports map[string]string) string {
+	if len(imports) == 0 {
+		return ""
+	}
+	pathToAlias := make(map[string]string, len(imports))
+	paths := make([]string, 0, len(imports))
+	for alias, path := range imports {
+		paths = append(paths, path)
+		pathToAlias[path] = alias
+	}
+	sort.Strings(paths)
+
+	lines := make([]string, 0, len(paths))
+	for _, path := range paths {
+		alias := pathToAlias[path]
+		if alias == "" || alias == pathBase(path) {
+			lines = append(lines, fmt.Sprintf("%q", path))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %q", alias, path))
+	}
+	return "import (\n" + strings.Join(lines, "\n") + "\n)"
+}
+
+func pathBase(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
 type tmplParam struct {
 	StructName    string
 	InterfaceName string
@@ -259,12 +1026,17 @@ type tmplParam struct {
 	Pointer       bool
 	Super         bool
 	Extends       bool
+	HasValidation bool
+	TypeParams    string
+	TypeArgs      string
 }
 
 type FieldInfo struct {
-	Type       string
-	Name       string
-	ConstValue string
+	Type        string
+	Name        string
+	ConstValue  string
+	Validations []validationCheck
+	Required    bool
 }
 
 func match(a, b []string) []string {
@@ -0,0 +1,179 @@
+package genconstructor
+
+import (
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+// embeddingStruct builds a *types.Struct for `type Outer struct { Base;
+// Extra string }`, with Base itself a one-field struct, so tests can
+// exercise promoted-field handling without a full packages.Load.
+func embeddingStruct() *types.Struct {
+	pkg := types.NewPackage("example.com/sample", "sample")
+	idField := types.NewField(token.NoPos, pkg, "ID", types.Typ[types.String], false)
+	base := types.NewStruct([]*types.Var{idField}, []string{`required:""`})
+	baseNamed := types.NewNamed(types.NewTypeName(token.NoPos, pkg, "Base", nil), base, nil)
+
+	baseField := types.NewField(token.NoPos, pkg, "Base", baseNamed, true)
+	extraField := types.NewField(token.NoPos, pkg, "Extra", types.Typ[types.String], false)
+	return types.NewStruct([]*types.Var{baseField, extraField}, []string{"", `required:""`})
+}
+
+// TestRenderConstructor_ValidationMessageEscaping guards against
+// validatorBuilders' Msg strings corrupting either the generated string
+// literal or the fmt.Errorf format string they're embedded in.
+func TestRenderConstructor_ValidationMessageEscaping(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		cond string
+		msg  string
+	}{
+		{
+			name: "percent in message",
+			rule: "regexp",
+			cond: `!regexp.MustCompile("^[0-9]+%$").MatchString(rate)`,
+			msg:  `Rate must match ^[0-9]+%$`,
+		},
+		{
+			name: "quote in message",
+			rule: "oneof",
+			cond: `note != "a\"b" && note != "c"`,
+			msg:  `Note must be one of a"b|c`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := renderConstructor(tmplParam{
+				StructName:    "Sample",
+				HasValidation: true,
+				Fields: []FieldInfo{
+					{
+						Type: "string",
+						Name: "Rate",
+						Validations: []validationCheck{
+							{Cond: tt.cond, Msg: tt.msg},
+						},
+					},
+				},
+			})
+			if err != nil {
+				t.Fatalf("renderConstructor returned error: %v", err)
+			}
+
+			src := "package sample\n" + string(b)
+			if _, err := parser.ParseFile(token.NewFileSet(), "sample_gen.go", src, 0); err != nil {
+				t.Fatalf("generated code is not valid Go: %v\n%s", err, src)
+			}
+			if strings.Contains(src, `fmt.Errorf("`+tt.msg+`")`) {
+				t.Fatalf("Msg was spliced unescaped into the format string:\n%s", src)
+			}
+		})
+	}
+}
+
+// TestRenderBuilder_RequiredFieldSetTracking guards against Build()
+// mistaking a required field's zero value for "never set": a legitimate
+// SetCount(0) must not be rejected.
+func TestRenderBuilder_RequiredFieldSetTracking(t *testing.T) {
+	b, err := renderBuilder(tmplParam{
+		StructName: "Counter",
+		Fields: []FieldInfo{
+			{Type: "int", Name: "Count", Required: true},
+			{Type: "string", Name: "Label"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("renderBuilder returned error: %v", err)
+	}
+
+	src := "package sample\n" + string(b)
+	if _, err := parser.ParseFile(token.NewFileSet(), "sample_gen.go", src, 0); err != nil {
+		t.Fatalf("generated code is not valid Go: %v\n%s", err, src)
+	}
+	if strings.Contains(src, "IsZero()") {
+		t.Fatalf("Build() still infers \"set\" from the zero value:\n%s", src)
+	}
+	if !strings.Contains(src, "countSet bool") || !strings.Contains(src, "b.countSet = true") {
+		t.Fatalf("Build() has no per-field set-tracking for Count:\n%s", src)
+	}
+	if !strings.Contains(src, "if !b.countSet {") {
+		t.Fatalf("Build() does not check the countSet flag:\n%s", src)
+	}
+}
+
+// TestDirectFields_KeepsEmbeddedStructAsOneField guards against
+// structFields (used by the composite-literal-emitting modes: the plain
+// marker and -s/-e) silently splicing a promoted field
+// in under its own name -- Foo{PromotedField: v} isn't legal Go for a
+// promoted field. An embedded struct must come through as a single
+// field, named after its type, with the tag on the embedded field itself
+// preserved so -s/-e can still see it.
+func TestDirectFields_KeepsEmbeddedStructAsOneField(t *testing.T) {
+	fields := directFields(embeddingStruct())
+
+	if len(fields) != 2 {
+		t.Fatalf("want 2 fields (Base, Extra), got %d: %+v", len(fields), fields)
+	}
+	if fields[0].Name != "Base" {
+		t.Fatalf("want the embedded field kept as \"Base\", got %q", fields[0].Name)
+	}
+	if _, ok := fields[0].Tag.Lookup("required"); ok {
+		t.Fatalf("Base carries no tag of its own in this fixture, but Lookup found one: %q", fields[0].Tag)
+	}
+}
+
+// TestFlattenFields_PromotesEmbeddedFields guards against the
+// field-assignment modes (-o/-b) losing a promoted field: unlike a
+// composite literal, o.ID = v is legal Go for a field promoted from an
+// embedded struct, so these two modes are expected to flatten it in.
+func TestFlattenFields_PromotesEmbeddedFields(t *testing.T) {
+	fields := flattenFields(embeddingStruct())
+
+	if len(fields) != 2 {
+		t.Fatalf("want 2 fields (ID, Extra), got %d: %+v", len(fields), fields)
+	}
+	if fields[0].Name != "ID" {
+		t.Fatalf("want the promoted field \"ID\", got %q", fields[0].Name)
+	}
+	if _, ok := fields[0].Tag.Lookup("required"); !ok {
+		t.Fatalf("want the promoted ID field to keep Base's own required tag, got %q", fields[0].Tag)
+	}
+}
+
+// TestRenderOptions_RequiredFieldIsPositional guards against a bare
+// `required:""` field silently getting an ordinary WithField option: it
+// must become a positional parameter of NewFoo instead, since there's no
+// default to fall back on when the caller never supplies it.
+func TestRenderOptions_RequiredFieldIsPositional(t *testing.T) {
+	b, err := renderOptions(tmplParam{
+		StructName: "Foo",
+		Fields: []FieldInfo{
+			{Type: "string", Name: "Key", ConstValue: `"default"`},
+			{Type: "string", Name: "ID", Required: true},
+			{Type: "int", Name: "Count"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("renderOptions returned error: %v", err)
+	}
+
+	src := "package sample\n" + string(b)
+	if _, err := parser.ParseFile(token.NewFileSet(), "sample_gen.go", src, 0); err != nil {
+		t.Fatalf("generated code is not valid Go: %v\n%s", err, src)
+	}
+	if strings.Contains(src, "WithID") {
+		t.Fatalf("required field ID must not get a WithID option:\n%s", src)
+	}
+	if !strings.Contains(src, "func NewFoo(\n\tid string,\n\topts ...FooOption,\n)") {
+		t.Fatalf("NewFoo must take the required field ID as a positional parameter:\n%s", src)
+	}
+	if !strings.Contains(src, "WithCount") {
+		t.Fatalf("the non-required field Count must still get a WithCount option:\n%s", src)
+	}
+}